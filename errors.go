@@ -0,0 +1,80 @@
+// Copyright 2016 Patrick Brosi
+// Authors: info@patrickbrosi.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ParseError is a single error encountered while parsing a GTFS feed. With
+// ParseOptions.CollectErrors unset, the first ParseError encountered is
+// returned directly from Feed.Parse; with it set, ParseErrors for erroneous
+// records are instead collected and retrievable via Feed.Errors, and parsing
+// continues.
+type ParseError struct {
+	File     string
+	Line     int
+	Message  string
+	Severity string
+}
+
+func (e ParseError) Error() string {
+	if e.Line < 0 {
+		return fmt.Sprintf("%s: %s", e.File, e.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Message)
+}
+
+func (feed *Feed) addError(file string, line int, e error, severity string) {
+	feed.errors = append(feed.errors, ParseError{file, line, e.Error(), severity})
+}
+
+// Errors returns the ParseErrors collected while parsing, in the order they
+// were encountered. It is only populated if ParseOptions.CollectErrors was
+// set.
+func (feed *Feed) Errors() []ParseError {
+	return feed.errors
+}
+
+// WriteValidationReport writes a human-readable report of the errors
+// collected via ParseOptions.CollectErrors to w, grouped by file.
+func (feed *Feed) WriteValidationReport(w io.Writer) error {
+	if len(feed.errors) == 0 {
+		_, e := fmt.Fprintln(w, "No errors.")
+		return e
+	}
+
+	byFile := make(map[string][]ParseError)
+	for _, e := range feed.errors {
+		byFile[e.File] = append(byFile[e.File], e)
+	}
+
+	files := make([]string, 0, len(byFile))
+	for f := range byFile {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+
+	for _, f := range files {
+		if _, e := fmt.Fprintf(w, "%s (%d error(s)):\n", f, len(byFile[f])); e != nil {
+			return e
+		}
+		for _, pe := range byFile[f] {
+			if pe.Line < 0 {
+				if _, e := fmt.Fprintf(w, "  [%s] %s\n", pe.Severity, pe.Message); e != nil {
+					return e
+				}
+			} else if _, e := fmt.Fprintf(w, "  [%s] line %d: %s\n", pe.Severity, pe.Line, pe.Message); e != nil {
+				return e
+			}
+		}
+	}
+
+	return nil
+}
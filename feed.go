@@ -13,6 +13,7 @@ import (
 	"github.com/patrickbr/gtfsparser/gtfs"
 	"io"
 	"math"
+	"net/http"
 	"os"
 	opath "path"
 	"sort"
@@ -22,6 +23,15 @@ type ParseOptions struct {
 	UseDefValueOnError bool
 	DropErroneous      bool
 	DryRun             bool
+	CollectErrors      bool
+
+	// HTTPClient is used by Feed.ParseURL. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+
+	// CacheDir, if non-empty, stores the downloaded zip and its ETag/
+	// Last-Modified response headers between calls to Feed.ParseURL, so an
+	// unmodified feed does not have to be re-downloaded or re-parsed.
+	CacheDir string
 }
 
 type Feed struct {
@@ -34,11 +44,17 @@ type Feed struct {
 	Shapes         map[string]*gtfs.Shape
 	Transfers      []*gtfs.Transfer
 	FeedInfos      []*gtfs.FeedInfo
+	Translations   []*gtfs.Translation
+	Levels         map[string]*gtfs.Level
+	Pathways       map[string]*gtfs.Pathway
 
 	zipFileCloser *zip.ReadCloser
 	curFileHandle *os.File
 
 	opts ParseOptions
+
+	stopIndex *StopIndex
+	errors    []ParseError
 }
 
 // Create a new, empty feed
@@ -53,7 +69,10 @@ func NewFeed() *Feed {
 		Shapes:         make(map[string]*gtfs.Shape),
 		Transfers:      make([]*gtfs.Transfer, 0),
 		FeedInfos:      make([]*gtfs.FeedInfo, 0),
-		opts:           ParseOptions{false, false, false},
+		Translations:   make([]*gtfs.Translation, 0),
+		Levels:         make(map[string]*gtfs.Level),
+		Pathways:       make(map[string]*gtfs.Pathway),
+		opts:           ParseOptions{false, false, false, false, nil, ""},
 	}
 	return &g
 }
@@ -70,9 +89,17 @@ func (feed *Feed) Parse(path string) error {
 	if e == nil {
 		e = feed.parseFeedInfos(path)
 	}
+	if e == nil {
+		// parse levels before stops, so stops can resolve level_id
+		e = feed.parseLevels(path)
+	}
 	if e == nil {
 		e = feed.parseStops(path)
 	}
+	if e == nil {
+		// pathways reference stops, so parse them afterwards
+		e = feed.parsePathways(path)
+	}
 	if e == nil {
 		e = feed.parseShapes(path)
 	}
@@ -137,6 +164,13 @@ func (feed *Feed) Parse(path string) error {
 	if e == nil {
 		e = feed.parseTransfers(path)
 	}
+	if e == nil {
+		e = feed.parseTranslations(path)
+	}
+
+	if e == nil {
+		feed.applyFeedInfoTranslations()
+	}
 
 	// close open readers
 	if feed.zipFileCloser != nil {
@@ -196,7 +230,7 @@ func (feed *Feed) parseAgencies(path string) (err error) {
 
 	defer func() {
 		if r := recover(); r != nil {
-			err = ParseError{"agency.txt", reader.Curline, r.(error).Error()}
+			err = ParseError{"agency.txt", reader.Curline, r.(error).Error(), "fatal"}
 		}
 	}()
 
@@ -206,6 +240,9 @@ func (feed *Feed) parseAgencies(path string) (err error) {
 		if e != nil {
 			if feed.opts.DropErroneous {
 				continue
+			} else if feed.opts.CollectErrors {
+				feed.addError("agency.txt", reader.Curline, e, "error")
+				continue
 			} else {
 				panic(e)
 			}
@@ -227,17 +264,21 @@ func (feed *Feed) parseStops(path string) (err error) {
 
 	defer func() {
 		if r := recover(); r != nil {
-			err = ParseError{"stops.txt", reader.Curline, r.(error).Error()}
+			err = ParseError{"stops.txt", reader.Curline, r.(error).Error(), "fatal"}
 		}
 	}()
 
 	var record map[string]string
 	parentStopIds := make(map[string]string, 0)
+	levelIds := make(map[string]string, 0)
 	for record = reader.ParseRecord(); record != nil; record = reader.ParseRecord() {
 		stop, e := createStop(record, &feed.opts)
 		if e != nil {
 			if feed.opts.DropErroneous {
 				continue
+			} else if feed.opts.CollectErrors {
+				feed.addError("stops.txt", reader.Curline, e, "error")
+				continue
 			} else {
 				panic(e)
 			}
@@ -245,9 +286,32 @@ func (feed *Feed) parseStops(path string) (err error) {
 		if v, in := record["parent_station"]; in && len(v) > 0 {
 			parentStopIds[stop.Id] = v
 		}
+		if v, in := record["level_id"]; in && len(v) > 0 {
+			levelIds[stop.Id] = v
+		}
 		feed.Stops[stop.Id] = stop
 	}
 
+	// write the levels
+	for id, lid := range levelIds {
+		level, ok := feed.Levels[lid]
+		if !ok {
+			if feed.opts.UseDefValueOnError {
+				continue
+			} else if feed.opts.DropErroneous {
+				delete(feed.Stops, id)
+			} else if feed.opts.CollectErrors {
+				feed.addError("stops.txt", -1, errors.New("(for stop id "+id+") No level with id "+lid+" found, cannot use as level_id here."), "error")
+			} else {
+				panic(errors.New("(for stop id " + id + ") No level with id " + lid + " found, cannot use as level_id here."))
+			}
+			continue
+		}
+		if stop, in := feed.Stops[id]; in {
+			stop.Level = level
+		}
+	}
+
 	// write the parent stop ids
 	for id, pid := range parentStopIds {
 		pstop, ok := feed.Stops[pid]
@@ -258,11 +322,18 @@ func (feed *Feed) parseStops(path string) (err error) {
 			} else if feed.opts.DropErroneous {
 				// delete the erroneous entry
 				delete(feed.Stops, id)
+				continue
+			} else if feed.opts.CollectErrors {
+				feed.addError("stops.txt", -1, errors.New("(for stop id "+id+") No station with id "+pid+" found, cannot use as parent station here."), "error")
+				delete(feed.Stops, id)
+				continue
 			} else {
 				panic(errors.New("(for stop id " + id + ") No station with id " + pid + " found, cannot use as parent station here."))
 			}
 		}
-		feed.Stops[id].Parent_station = pstop
+		if stop, in := feed.Stops[id]; in {
+			stop.Parent_station = pstop
+		}
 	}
 
 	return e
@@ -279,7 +350,7 @@ func (feed *Feed) parseRoutes(path string) (err error) {
 
 	defer func() {
 		if r := recover(); r != nil {
-			err = ParseError{"routes.txt", reader.Curline, r.(error).Error()}
+			err = ParseError{"routes.txt", reader.Curline, r.(error).Error(), "fatal"}
 		}
 	}()
 
@@ -289,6 +360,9 @@ func (feed *Feed) parseRoutes(path string) (err error) {
 		if e != nil {
 			if feed.opts.DropErroneous {
 				continue
+			} else if feed.opts.CollectErrors {
+				feed.addError("routes.txt", reader.Curline, e, "error")
+				continue
 			} else {
 				panic(e)
 			}
@@ -313,7 +387,7 @@ func (feed *Feed) parseCalendar(path string) (err error) {
 
 	defer func() {
 		if r := recover(); r != nil {
-			err = ParseError{"calendar.txt", reader.Curline, r.(error).Error()}
+			err = ParseError{"calendar.txt", reader.Curline, r.(error).Error(), "fatal"}
 		}
 	}()
 
@@ -324,6 +398,9 @@ func (feed *Feed) parseCalendar(path string) (err error) {
 		if e != nil {
 			if feed.opts.DropErroneous {
 				continue
+			} else if feed.opts.CollectErrors {
+				feed.addError("calendar.txt", reader.Curline, e, "error")
+				continue
 			} else {
 				panic(e)
 			}
@@ -353,7 +430,7 @@ func (feed *Feed) parseCalendarDates(path string) (err error) {
 
 	defer func() {
 		if r := recover(); r != nil {
-			err = ParseError{"calendar_dates.txt", reader.Curline, r.(error).Error()}
+			err = ParseError{"calendar_dates.txt", reader.Curline, r.(error).Error(), "fatal"}
 		}
 	}()
 
@@ -364,6 +441,9 @@ func (feed *Feed) parseCalendarDates(path string) (err error) {
 		if e != nil {
 			if feed.opts.DropErroneous {
 				continue
+			} else if feed.opts.CollectErrors {
+				feed.addError("calendar_dates.txt", reader.Curline, e, "error")
+				continue
 			} else {
 				panic(e)
 			}
@@ -393,7 +473,7 @@ func (feed *Feed) parseTrips(path string) (err error) {
 
 	defer func() {
 		if r := recover(); r != nil {
-			err = ParseError{"trips.txt", reader.Curline, r.(error).Error()}
+			err = ParseError{"trips.txt", reader.Curline, r.(error).Error(), "fatal"}
 		}
 	}()
 
@@ -403,6 +483,9 @@ func (feed *Feed) parseTrips(path string) (err error) {
 		if e != nil {
 			if feed.opts.DropErroneous {
 				continue
+			} else if feed.opts.CollectErrors {
+				feed.addError("trips.txt", reader.Curline, e, "error")
+				continue
 			} else {
 				panic(e)
 			}
@@ -424,7 +507,7 @@ func (feed *Feed) parseShapes(path string) (err error) {
 
 	defer func() {
 		if r := recover(); r != nil {
-			err = ParseError{"shapes.txt", reader.Curline, r.(error).Error()}
+			err = ParseError{"shapes.txt", reader.Curline, r.(error).Error(), "fatal"}
 		}
 	}()
 
@@ -434,6 +517,9 @@ func (feed *Feed) parseShapes(path string) (err error) {
 		if e != nil {
 			if feed.opts.DropErroneous {
 				continue
+			} else if feed.opts.CollectErrors {
+				feed.addError("shapes.txt", reader.Curline, e, "error")
+				continue
 			} else {
 				panic(e)
 			}
@@ -453,7 +539,7 @@ func (feed *Feed) parseStopTimes(path string) (err error) {
 
 	defer func() {
 		if r := recover(); r != nil {
-			err = ParseError{"stop_times.txt", reader.Curline, r.(error).Error()}
+			err = ParseError{"stop_times.txt", reader.Curline, r.(error).Error(), "fatal"}
 		}
 	}()
 
@@ -464,6 +550,9 @@ func (feed *Feed) parseStopTimes(path string) (err error) {
 		if e != nil {
 			if feed.opts.DropErroneous {
 				continue
+			} else if feed.opts.CollectErrors {
+				feed.addError("stop_times.txt", reader.Curline, e, "error")
+				continue
 			} else {
 				panic(e)
 			}
@@ -483,7 +572,7 @@ func (feed *Feed) parseFrequencies(path string) (err error) {
 
 	defer func() {
 		if r := recover(); r != nil {
-			err = ParseError{"frequencies.txt", reader.Curline, r.(error).Error()}
+			err = ParseError{"frequencies.txt", reader.Curline, r.(error).Error(), "fatal"}
 		}
 	}()
 
@@ -493,6 +582,9 @@ func (feed *Feed) parseFrequencies(path string) (err error) {
 		if e != nil {
 			if feed.opts.DropErroneous {
 				continue
+			} else if feed.opts.CollectErrors {
+				feed.addError("frequencies.txt", reader.Curline, e, "error")
+				continue
 			} else {
 				panic(e)
 			}
@@ -512,7 +604,7 @@ func (feed *Feed) parseFareAttributes(path string) (err error) {
 
 	defer func() {
 		if r := recover(); r != nil {
-			err = ParseError{"fare_attributes.txt", reader.Curline, r.(error).Error()}
+			err = ParseError{"fare_attributes.txt", reader.Curline, r.(error).Error(), "fatal"}
 		}
 	}()
 
@@ -522,6 +614,9 @@ func (feed *Feed) parseFareAttributes(path string) (err error) {
 		if e != nil {
 			if feed.opts.DropErroneous {
 				continue
+			} else if feed.opts.CollectErrors {
+				feed.addError("fare_attributes.txt", reader.Curline, e, "error")
+				continue
 			} else {
 				panic(e)
 			}
@@ -542,7 +637,7 @@ func (feed *Feed) parseFareAttributeRules(path string) (err error) {
 
 	defer func() {
 		if r := recover(); r != nil {
-			err = ParseError{"fare_rules.txt", reader.Curline, r.(error).Error()}
+			err = ParseError{"fare_rules.txt", reader.Curline, r.(error).Error(), "fatal"}
 		}
 	}()
 
@@ -552,6 +647,9 @@ func (feed *Feed) parseFareAttributeRules(path string) (err error) {
 		if e != nil {
 			if feed.opts.DropErroneous {
 				continue
+			} else if feed.opts.CollectErrors {
+				feed.addError("fare_rules.txt", reader.Curline, e, "error")
+				continue
 			} else {
 				panic(e)
 			}
@@ -571,7 +669,7 @@ func (feed *Feed) parseTransfers(path string) (err error) {
 
 	defer func() {
 		if r := recover(); r != nil {
-			err = ParseError{"transfers.txt", reader.Curline, r.(error).Error()}
+			err = ParseError{"transfers.txt", reader.Curline, r.(error).Error(), "fatal"}
 		}
 	}()
 
@@ -581,6 +679,9 @@ func (feed *Feed) parseTransfers(path string) (err error) {
 		if e != nil {
 			if feed.opts.DropErroneous {
 				continue
+			} else if feed.opts.CollectErrors {
+				feed.addError("transfers.txt", reader.Curline, e, "error")
+				continue
 			} else {
 				panic(e)
 			}
@@ -603,7 +704,7 @@ func (feed *Feed) parseFeedInfos(path string) (err error) {
 
 	defer func() {
 		if r := recover(); r != nil {
-			err = ParseError{"feed_info.txt", reader.Curline, r.(error).Error()}
+			err = ParseError{"feed_info.txt", reader.Curline, r.(error).Error(), "fatal"}
 		}
 	}()
 
@@ -613,6 +714,9 @@ func (feed *Feed) parseFeedInfos(path string) (err error) {
 		if e != nil {
 			if feed.opts.DropErroneous {
 				continue
+			} else if feed.opts.CollectErrors {
+				feed.addError("feed_info.txt", reader.Curline, e, "error")
+				continue
 			} else {
 				panic(e)
 			}
@@ -625,6 +729,133 @@ func (feed *Feed) parseFeedInfos(path string) (err error) {
 	return e
 }
 
+func (feed *Feed) parseLevels(path string) (err error) {
+	file, e := feed.getFile(path, "levels.txt")
+
+	if e != nil {
+		return nil
+	}
+	reader := NewCsvParser(file)
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = ParseError{"levels.txt", reader.Curline, r.(error).Error(), "fatal"}
+		}
+	}()
+
+	var record map[string]string
+	for record = reader.ParseRecord(); record != nil; record = reader.ParseRecord() {
+		level, e := createLevel(record, &feed.opts)
+		if e != nil {
+			if feed.opts.DropErroneous {
+				continue
+			} else if feed.opts.CollectErrors {
+				feed.addError("levels.txt", reader.Curline, e, "error")
+				continue
+			} else {
+				panic(e)
+			}
+		}
+		feed.Levels[level.Id] = level
+	}
+
+	return e
+}
+
+func (feed *Feed) parsePathways(path string) (err error) {
+	file, e := feed.getFile(path, "pathways.txt")
+
+	if e != nil {
+		return nil
+	}
+	reader := NewCsvParser(file)
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = ParseError{"pathways.txt", reader.Curline, r.(error).Error(), "fatal"}
+		}
+	}()
+
+	var record map[string]string
+	for record = reader.ParseRecord(); record != nil; record = reader.ParseRecord() {
+		pw, e := createPathway(record, feed.Stops, &feed.opts)
+		if e != nil {
+			if feed.opts.DropErroneous {
+				continue
+			} else if feed.opts.CollectErrors {
+				feed.addError("pathways.txt", reader.Curline, e, "error")
+				continue
+			} else {
+				panic(e)
+			}
+		}
+		feed.Pathways[pw.Id] = pw
+	}
+
+	return e
+}
+
+func (feed *Feed) parseTranslations(path string) (err error) {
+	file, e := feed.getFile(path, "translations.txt")
+
+	if e != nil {
+		return nil
+	}
+	reader := NewCsvParser(file)
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = ParseError{"translations.txt", reader.Curline, r.(error).Error(), "fatal"}
+		}
+	}()
+
+	var record map[string]string
+	for record = reader.ParseRecord(); record != nil; record = reader.ParseRecord() {
+		t, e := createTranslation(record, &feed.opts)
+		if e != nil {
+			if feed.opts.DropErroneous {
+				continue
+			} else if feed.opts.CollectErrors {
+				feed.addError("translations.txt", reader.Curline, e, "error")
+				continue
+			} else {
+				panic(e)
+			}
+		}
+		if t != nil && !feed.opts.DryRun {
+			feed.Translations = append(feed.Translations, t)
+		}
+	}
+
+	return e
+}
+
+// applyFeedInfoTranslations fills in FeedInfo.PublisherNames/PublisherUrls
+// from the parsed translations.txt, matched against feed_info by
+// field_value since feed_info.txt has no natural record id.
+func (feed *Feed) applyFeedInfoTranslations() {
+	for _, fi := range feed.FeedInfos {
+		fi.PublisherNames = make(map[string]string)
+		fi.PublisherUrls = make(map[string]string)
+
+		for _, t := range feed.Translations {
+			if t.Table_name != "feed_info" {
+				continue
+			}
+			switch t.Field_name {
+			case "feed_publisher_name":
+				if t.Field_value == "" || t.Field_value == fi.Publisher_name {
+					fi.PublisherNames[t.Language] = t.Translation
+				}
+			case "feed_publisher_url":
+				if fi.Publisher_url != nil && (t.Field_value == "" || t.Field_value == fi.Publisher_url.String()) {
+					fi.PublisherUrls[t.Language] = t.Translation
+				}
+			}
+		}
+	}
+}
+
 func (feed *Feed) checkShapeMeasure(shape *gtfs.Shape, opt *ParseOptions) error {
 	max := float32(math.Inf(-1))
 	deleted := 0
@@ -640,6 +871,8 @@ func (feed *Feed) checkShapeMeasure(shape *gtfs.Shape, opt *ParseOptions) error
 			} else if opt.DropErroneous {
 				shape.Points = shape.Points[:i+copy(shape.Points[i:], shape.Points[i+1:])]
 				deleted++
+			} else if opt.CollectErrors {
+				feed.addError("shapes.txt", -1, fmt.Errorf("In shape '%s' for point with seq=%d shape_dist_traveled doeas not increase along with stop_sequence (%f > %f)", shape.Id, shape.Points[i].Sequence, max, shape.Points[i].Dist_traveled), "error")
 			} else {
 				return (errors.New(fmt.Sprintf("In shape '%s' for point with seq=%d shape_dist_traveled doeas not increase along with stop_sequence (%f > %f)", shape.Id, shape.Points[i].Sequence, max, shape.Points[i].Dist_traveled)))
 			}
@@ -658,6 +891,8 @@ func (feed *Feed) checkStopTimeMeasure(trip *gtfs.Trip, opt *ParseOptions) error
 			if opt.DropErroneous {
 				trip.StopTimes = trip.StopTimes[:i+copy(trip.StopTimes[i:], trip.StopTimes[i+1:])]
 				deleted++
+			} else if opt.CollectErrors {
+				feed.addError("stop_times.txt", -1, fmt.Errorf("In trip '%s' for stoptime with seq=%d the arrival time is before the departure in the previous station.", trip.Id, trip.StopTimes[i].Sequence), "error")
 			} else {
 				return (errors.New(fmt.Sprintf("In trip '%s' for stoptime with seq=%d the arrival time is before the departure in the previous station.", trip.Id, trip.StopTimes[i].Sequence)))
 			}
@@ -674,6 +909,8 @@ func (feed *Feed) checkStopTimeMeasure(trip *gtfs.Trip, opt *ParseOptions) error
 			} else if opt.DropErroneous {
 				trip.StopTimes = trip.StopTimes[:i+copy(trip.StopTimes[i:], trip.StopTimes[i+1:])]
 				deleted++
+			} else if opt.CollectErrors {
+				feed.addError("stop_times.txt", -1, fmt.Errorf("In trip '%s' for stoptime with seq=%d shape_dist_traveled doeas not increase along with stop_sequence (%f > %f)", trip.Id, trip.StopTimes[i].Sequence, max, trip.StopTimes[i].Shape_dist_traveled), "error")
 			} else {
 				return (errors.New(fmt.Sprintf("In trip '%s' for stoptime with seq=%d shape_dist_traveled doeas not increase along with stop_sequence (%f > %f)", trip.Id, trip.StopTimes[i].Sequence, max, trip.StopTimes[i].Shape_dist_traveled)))
 			}
@@ -18,4 +18,8 @@ type FeedInfo struct {
 	End_date       Date
 	Phone          string
 	Version        string
+
+	// keyed by BCP 47 language tag, populated from translations.txt
+	PublisherNames map[string]string
+	PublisherUrls  map[string]string
 }
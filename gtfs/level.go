@@ -0,0 +1,13 @@
+// Copyright 2016 Patrick Brosi
+// Authors: info@patrickbrosi.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfs
+
+type Level struct {
+	Id    string
+	Index float32
+	Name  string
+}
@@ -0,0 +1,22 @@
+// Copyright 2016 Patrick Brosi
+// Authors: info@patrickbrosi.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfs
+
+type Pathway struct {
+	Id                   string
+	FromStop             *Stop
+	ToStop               *Stop
+	Mode                 int8
+	IsBidirectional      bool
+	Length               float32
+	TraversalTime        int
+	StairCount           int
+	MaxSlope             float32
+	MinWidth             float32
+	SignpostedAs         string
+	ReversedSignpostedAs string
+}
@@ -0,0 +1,17 @@
+// Copyright 2016 Patrick Brosi
+// Authors: info@patrickbrosi.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfs
+
+type Translation struct {
+	Table_name    string
+	Field_name    string
+	Language      string
+	Translation   string
+	Record_id     string
+	Record_sub_id string
+	Field_value   string
+}
@@ -0,0 +1,150 @@
+// Copyright 2016 Patrick Brosi
+// Authors: info@patrickbrosi.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	opath "path"
+)
+
+// ErrNotModified is returned by Feed.ParseURL if the server reports (via
+// ETag/Last-Modified) that the feed has not changed since the last call, so
+// the cached copy on disk was not re-parsed.
+var ErrNotModified = errors.New("gtfsparser: feed not modified since last fetch")
+
+type urlCacheMeta struct {
+	ETag         string `json:"etag"`
+	LastModified string `json:"last_modified"`
+}
+
+// ParseURL downloads the zip feed at url and parses it, the same as Parse
+// would for a local zip file. The zip is fully buffered before parsing,
+// since archive/zip needs random access.
+//
+// If ParseOptions.CacheDir is set, the downloaded zip and the response's
+// ETag/Last-Modified headers are cached there, keyed by a hash of url. On
+// the next call for the same url, those headers are sent as If-None-Match/
+// If-Modified-Since; if the server answers 304 Not Modified, ParseURL
+// returns ErrNotModified without re-parsing the cached zip.
+func (feed *Feed) ParseURL(url string) error {
+	client := feed.opts.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	cacheKey := cacheKeyFor(url)
+	zipPath := ""
+	metaPath := ""
+	var meta urlCacheMeta
+
+	if len(feed.opts.CacheDir) > 0 {
+		zipPath = opath.Join(feed.opts.CacheDir, cacheKey+".zip")
+		metaPath = opath.Join(feed.opts.CacheDir, cacheKey+".json")
+		meta = readCacheMeta(metaPath)
+	}
+
+	req, e := http.NewRequest("GET", url, nil)
+	if e != nil {
+		return e
+	}
+	if len(meta.ETag) > 0 {
+		req.Header.Set("If-None-Match", meta.ETag)
+	}
+	if len(meta.LastModified) > 0 {
+		req.Header.Set("If-Modified-Since", meta.LastModified)
+	}
+
+	resp, e := client.Do(req)
+	if e != nil {
+		return e
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return ErrNotModified
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("gtfsparser: got status " + resp.Status + " fetching " + url)
+	}
+
+	body, e := ioutil.ReadAll(resp.Body)
+	if e != nil {
+		return e
+	}
+
+	if len(zipPath) > 0 {
+		if e := writeCacheFile(zipPath, body); e != nil {
+			return e
+		}
+		meta.ETag = resp.Header.Get("ETag")
+		meta.LastModified = resp.Header.Get("Last-Modified")
+		writeCacheMeta(metaPath, meta)
+	} else {
+		tmp, e := ioutil.TempFile("", "gtfsparser-*.zip")
+		if e != nil {
+			return e
+		}
+		defer os.Remove(tmp.Name())
+		if _, e := tmp.Write(body); e != nil {
+			tmp.Close()
+			return e
+		}
+		tmp.Close()
+		zipPath = tmp.Name()
+	}
+
+	return feed.Parse(zipPath)
+}
+
+func cacheKeyFor(url string) string {
+	sum := sha1.Sum([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+func readCacheMeta(path string) urlCacheMeta {
+	var meta urlCacheMeta
+
+	f, e := os.Open(path)
+	if e != nil {
+		return meta
+	}
+	defer f.Close()
+
+	json.NewDecoder(f).Decode(&meta)
+	return meta
+}
+
+func writeCacheMeta(path string, meta urlCacheMeta) error {
+	f, e := os.Create(path)
+	if e != nil {
+		return e
+	}
+	defer f.Close()
+
+	return json.NewEncoder(f).Encode(meta)
+}
+
+func writeCacheFile(path string, data []byte) error {
+	if e := os.MkdirAll(opath.Dir(path), 0755); e != nil {
+		return e
+	}
+
+	f, e := os.Create(path)
+	if e != nil {
+		return e
+	}
+	defer f.Close()
+
+	_, e = f.Write(data)
+	return e
+}
@@ -0,0 +1,192 @@
+// Copyright 2016 Patrick Brosi
+// Authors: info@patrickbrosi.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"github.com/dhconnelly/rtreego"
+	"github.com/patrickbr/gtfsparser/gtfs"
+	"math"
+	"sort"
+)
+
+const earthRadiusMeters = 6371000.0
+
+// stationLocationType is the GTFS stops.txt location_type value for a
+// station (as opposed to a stop/platform, entrance, generic node, or
+// boarding area).
+const stationLocationType = 1
+
+// StopIndex is a 2-D spatial index over a Feed's stops, built by
+// Feed.BuildStopIndex. feed.Stops is a plain map, so the index has no way to
+// observe stops added to it after the index was built; call
+// Feed.InvalidateStopIndex (and then Feed.BuildStopIndex again) whenever you
+// mutate feed.Stops post-parse.
+type StopIndex struct {
+	tree *rtreego.Rtree
+	size int
+}
+
+type indexedStop struct {
+	stop *gtfs.Stop
+	lat  float64
+	lon  float64
+}
+
+func (s *indexedStop) Bounds() *rtreego.Rect {
+	rect, _ := rtreego.NewRect(rtreego.Point{s.lat, s.lon}, []float64{1e-9, 1e-9})
+	return rect
+}
+
+// BuildStopIndex builds a spatial index over the feed's stops. Stops with
+// location_type == 1 (stations) are excluded unless includeStations is true.
+// The returned index is also cached on the feed for NearestStops,
+// StopsInBBox and NearestStopsWithinRadius to use.
+func (feed *Feed) BuildStopIndex(includeStations bool) *StopIndex {
+	tree := rtreego.NewTree(2, 25, 50)
+
+	size := 0
+	for _, stop := range feed.Stops {
+		if stop.Location_type == stationLocationType && !includeStations {
+			continue
+		}
+		tree.Insert(&indexedStop{stop, float64(stop.Lat), float64(stop.Lon)})
+		size++
+	}
+
+	idx := &StopIndex{tree: tree, size: size}
+	feed.stopIndex = idx
+	return idx
+}
+
+// InvalidateStopIndex discards the cached spatial index built by
+// BuildStopIndex. NearestStops, StopsInBBox and NearestStopsWithinRadius
+// return nil until BuildStopIndex is called again. Call this after adding
+// stops to feed.Stops post-parse, since the feed cannot detect that mutation
+// on its own.
+func (feed *Feed) InvalidateStopIndex() {
+	feed.stopIndex = nil
+}
+
+// NearestStops returns the k nearest stops to (lat, lon), ordered by
+// distance. BuildStopIndex must have been called first.
+func (feed *Feed) NearestStops(lat float64, lon float64, k int) []*gtfs.Stop {
+	if feed.stopIndex == nil {
+		return nil
+	}
+	return feed.stopIndex.NearestStops(lat, lon, k)
+}
+
+// StopsInBBox returns all indexed stops inside the given bounding box.
+// BuildStopIndex must have been called first.
+func (feed *Feed) StopsInBBox(minLat float64, minLon float64, maxLat float64, maxLon float64) []*gtfs.Stop {
+	if feed.stopIndex == nil {
+		return nil
+	}
+	return feed.stopIndex.StopsInBBox(minLat, minLon, maxLat, maxLon)
+}
+
+// NearestStopsWithinRadius returns all indexed stops within meters of
+// (lat, lon). BuildStopIndex must have been called first.
+func (feed *Feed) NearestStopsWithinRadius(lat float64, lon float64, meters float64) []*gtfs.Stop {
+	if feed.stopIndex == nil {
+		return nil
+	}
+	return feed.stopIndex.NearestStopsWithinRadius(lat, lon, meters)
+}
+
+// candidateFanout is how many extra candidates NearestStops pulls from the
+// tree per requested neighbor before re-ranking by equirectDistance, to
+// compensate for rtreego ranking in raw (lat, lon) degree space.
+const candidateFanout = 8
+
+func (idx *StopIndex) NearestStops(lat float64, lon float64, k int) []*gtfs.Stop {
+	if k <= 0 || idx.size == 0 {
+		return nil
+	}
+
+	fetch := k * candidateFanout
+	if fetch > idx.size {
+		fetch = idx.size
+	}
+
+	// rtreego.NearestNeighbors ranks by Euclidean distance over raw
+	// (lat, lon) degrees; away from the equator a degree of longitude is
+	// shorter than a degree of latitude, so that ordering is wrong. Overfetch
+	// candidates, then re-rank by the equirectangular approximation used
+	// elsewhere in this file and truncate to k.
+	results := idx.tree.NearestNeighbors(fetch, rtreego.Point{lat, lon})
+
+	type ranked struct {
+		stop *gtfs.Stop
+		dist float64
+	}
+
+	candidates := make([]ranked, 0, len(results))
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
+		s := r.(*indexedStop).stop
+		candidates = append(candidates, ranked{s, equirectDistance(lat, lon, float64(s.Lat), float64(s.Lon))})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].dist < candidates[j].dist })
+
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+
+	stops := make([]*gtfs.Stop, len(candidates))
+	for i, c := range candidates {
+		stops[i] = c.stop
+	}
+
+	return stops
+}
+
+func (idx *StopIndex) StopsInBBox(minLat float64, minLon float64, maxLat float64, maxLon float64) []*gtfs.Stop {
+	rect, e := rtreego.NewRect(rtreego.Point{minLat, minLon}, []float64{maxLat - minLat, maxLon - minLon})
+	if e != nil {
+		return nil
+	}
+
+	results := idx.tree.SearchIntersect(rect)
+
+	stops := make([]*gtfs.Stop, 0, len(results))
+	for _, r := range results {
+		stops = append(stops, r.(*indexedStop).stop)
+	}
+
+	return stops
+}
+
+func (idx *StopIndex) NearestStopsWithinRadius(lat float64, lon float64, meters float64) []*gtfs.Stop {
+	// bound the search by a bbox spanning the radius in degrees (equirectangular
+	// approximation at lat, no extra margin), then filter by equirectDistance below
+	latDelta := meters / earthRadiusMeters * (180 / math.Pi)
+	lonDelta := meters / (earthRadiusMeters * math.Cos(lat*math.Pi/180)) * (180 / math.Pi)
+
+	candidates := idx.StopsInBBox(lat-latDelta, lon-lonDelta, lat+latDelta, lon+lonDelta)
+
+	stops := make([]*gtfs.Stop, 0, len(candidates))
+	for _, s := range candidates {
+		if equirectDistance(lat, lon, float64(s.Lat), float64(s.Lon)) <= meters {
+			stops = append(stops, s)
+		}
+	}
+
+	return stops
+}
+
+// equirectDistance approximates the great-circle distance in meters between
+// two lat/lon points. This is accurate enough for stop-proximity queries
+// and keeps the hot path free of trigonometric haversine calls.
+func equirectDistance(lat1 float64, lon1 float64, lat2 float64, lon2 float64) float64 {
+	x := (lon2 - lon1) * math.Pi / 180 * math.Cos((lat1+lat2)/2*math.Pi/180)
+	y := (lat2 - lat1) * math.Pi / 180
+	return math.Sqrt(x*x+y*y) * earthRadiusMeters
+}
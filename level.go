@@ -0,0 +1,38 @@
+// Copyright 2016 Patrick Brosi
+// Authors: info@patrickbrosi.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"errors"
+	"github.com/patrickbr/gtfsparser/gtfs"
+	"strconv"
+)
+
+func createLevel(record map[string]string, opts *ParseOptions) (l *gtfs.Level, err error) {
+	if _, in := record["level_id"]; !in {
+		return nil, errors.New("level_id is required.")
+	}
+	if _, in := record["level_index"]; !in {
+		return nil, errors.New("level_index is required.")
+	}
+
+	l = new(gtfs.Level)
+	l.Id = record["level_id"]
+	l.Name = record["level_name"]
+
+	index, e := strconv.ParseFloat(record["level_index"], 32)
+	if e != nil {
+		if opts.UseDefValueOnError {
+			index = 0
+		} else {
+			return nil, errors.New("level_index '" + record["level_index"] + "' is not a valid float.")
+		}
+	}
+	l.Index = float32(index)
+
+	return l, nil
+}
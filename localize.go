@@ -0,0 +1,114 @@
+// Copyright 2016 Patrick Brosi
+// Authors: info@patrickbrosi.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"github.com/patrickbr/gtfsparser/gtfs"
+	"golang.org/x/text/language"
+)
+
+// Localized returns the best-matching translation of fieldName on entity for
+// preferred, falling back to the value already set on entity (which is
+// itself the feed_info.txt feed_lang value per the GTFS spec) if no
+// translation matches. entity must be one of *gtfs.Stop, *gtfs.Route,
+// *gtfs.Trip or *gtfs.FeedInfo.
+func (feed *Feed) Localized(entity interface{}, fieldName string, preferred []language.Tag) string {
+	tableName, recordId, fallback := localizableInfo(entity, fieldName)
+	if len(tableName) == 0 {
+		return fallback
+	}
+
+	var tags []language.Tag
+	var vals []string
+
+	for _, t := range feed.translationsFor(tableName, recordId, fieldName, fallback) {
+		tag, e := language.Parse(t.Language)
+		if e != nil {
+			continue
+		}
+		tags = append(tags, tag)
+		vals = append(vals, t.Translation)
+	}
+
+	if len(tags) == 0 {
+		return fallback
+	}
+
+	matcher := language.NewMatcher(tags)
+	_, idx, conf := matcher.Match(preferred...)
+	if conf == language.No {
+		return fallback
+	}
+
+	return vals[idx]
+}
+
+// translationsFor returns all translations.txt rows for tableName/fieldName
+// that apply to recordId, supporting both the current record_id-keyed form
+// and the legacy field_value-keyed form.
+func (feed *Feed) translationsFor(tableName string, recordId string, fieldName string, fallback string) []*gtfs.Translation {
+	var matches []*gtfs.Translation
+
+	for _, t := range feed.Translations {
+		if t.Table_name != tableName || t.Field_name != fieldName {
+			continue
+		}
+
+		if len(t.Record_id) > 0 {
+			if t.Record_id == recordId {
+				matches = append(matches, t)
+			}
+		} else if t.Field_value == fallback {
+			matches = append(matches, t)
+		}
+	}
+
+	return matches
+}
+
+func localizableInfo(entity interface{}, fieldName string) (tableName string, recordId string, fallback string) {
+	switch e := entity.(type) {
+	case *gtfs.Stop:
+		switch fieldName {
+		case "stop_name":
+			fallback = e.Name
+		case "stop_desc":
+			fallback = e.Desc
+		}
+		return "stops", e.Id, fallback
+	case *gtfs.Route:
+		switch fieldName {
+		case "route_short_name":
+			fallback = e.Short_name
+		case "route_long_name":
+			fallback = e.Long_name
+		case "route_desc":
+			fallback = e.Desc
+		}
+		return "routes", e.Id, fallback
+	case *gtfs.Trip:
+		switch fieldName {
+		case "trip_headsign":
+			fallback = e.Headsign
+		case "trip_short_name":
+			fallback = e.Short_name
+		}
+		return "trips", e.Id, fallback
+	case *gtfs.FeedInfo:
+		switch fieldName {
+		case "feed_publisher_name":
+			fallback = e.Publisher_name
+		case "feed_publisher_url":
+			if e.Publisher_url != nil {
+				fallback = e.Publisher_url.String()
+			}
+		}
+		return "feed_info", "", fallback
+	}
+
+	return "", "", ""
+}
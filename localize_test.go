@@ -0,0 +1,64 @@
+// Copyright 2016 Patrick Brosi
+// Authors: info@patrickbrosi.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"testing"
+
+	"github.com/patrickbr/gtfsparser/gtfs"
+	"golang.org/x/text/language"
+)
+
+func TestLocalizedStopName(t *testing.T) {
+	feed := NewFeed()
+
+	stop := &gtfs.Stop{Id: "s1", Name: "Main St"}
+	feed.Stops[stop.Id] = stop
+
+	feed.Translations = append(feed.Translations, &gtfs.Translation{
+		Table_name:  "stops",
+		Field_name:  "stop_name",
+		Language:    "de",
+		Translation: "Hauptstraße",
+		Record_id:   "s1",
+	})
+
+	got := feed.Localized(stop, "stop_name", []language.Tag{language.German})
+	if got != "Hauptstraße" {
+		t.Errorf("Localized(stop_name, de) = %q, want %q", got, "Hauptstraße")
+	}
+
+	got = feed.Localized(stop, "stop_name", []language.Tag{language.French})
+	if got != "Main St" {
+		t.Errorf("Localized(stop_name, fr) = %q, want fallback %q", got, "Main St")
+	}
+}
+
+func TestLocalizedFeedPublisherName(t *testing.T) {
+	feed := NewFeed()
+
+	fi := &gtfs.FeedInfo{Publisher_name: "Acme Transit"}
+	feed.FeedInfos = append(feed.FeedInfos, fi)
+
+	feed.Translations = append(feed.Translations, &gtfs.Translation{
+		Table_name:  "feed_info",
+		Field_name:  "feed_publisher_name",
+		Language:    "de",
+		Translation: "Acme Verkehrsbetriebe",
+		Field_value: "Acme Transit",
+	})
+
+	got := feed.Localized(fi, "feed_publisher_name", []language.Tag{language.German})
+	if got != "Acme Verkehrsbetriebe" {
+		t.Errorf("Localized(feed_publisher_name, de) = %q, want %q", got, "Acme Verkehrsbetriebe")
+	}
+
+	got = feed.Localized(fi, "feed_publisher_name", []language.Tag{language.French})
+	if got != "Acme Transit" {
+		t.Errorf("Localized(feed_publisher_name, fr) = %q, want fallback %q", got, "Acme Transit")
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2016 Patrick Brosi
+// Authors: info@patrickbrosi.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"errors"
+	"github.com/patrickbr/gtfsparser/gtfs"
+	"strconv"
+)
+
+func createPathway(record map[string]string, stops map[string]*gtfs.Stop, opts *ParseOptions) (p *gtfs.Pathway, err error) {
+	if _, in := record["pathway_id"]; !in {
+		return nil, errors.New("pathway_id is required.")
+	}
+	if _, in := record["from_stop_id"]; !in {
+		return nil, errors.New("from_stop_id is required.")
+	}
+	if _, in := record["to_stop_id"]; !in {
+		return nil, errors.New("to_stop_id is required.")
+	}
+
+	fromStop, ok := stops[record["from_stop_id"]]
+	if !ok {
+		return nil, errors.New("(for pathway id " + record["pathway_id"] + ") No stop with id " + record["from_stop_id"] + " found, cannot use as from_stop_id.")
+	}
+	toStop, ok := stops[record["to_stop_id"]]
+	if !ok {
+		return nil, errors.New("(for pathway id " + record["pathway_id"] + ") No stop with id " + record["to_stop_id"] + " found, cannot use as to_stop_id.")
+	}
+
+	if (fromStop.Location_type == stationLocationType || toStop.Location_type == stationLocationType) && !opts.UseDefValueOnError {
+		return nil, errors.New("(for pathway id " + record["pathway_id"] + ") a pathway may not have a station as an endpoint.")
+	}
+
+	p = new(gtfs.Pathway)
+	p.Id = record["pathway_id"]
+	p.FromStop = fromStop
+	p.ToStop = toStop
+	p.SignpostedAs = record["signposted_as"]
+	p.ReversedSignpostedAs = record["reversed_signposted_as"]
+
+	if v, e := strconv.ParseInt(record["pathway_mode"], 10, 8); e == nil {
+		p.Mode = int8(v)
+	} else if !opts.UseDefValueOnError {
+		return nil, errors.New("pathway_mode '" + record["pathway_mode"] + "' is not a valid integer.")
+	}
+
+	p.IsBidirectional = record["is_bidirectional"] == "1"
+
+	if v, in := record["length"]; in && len(v) > 0 {
+		if f, e := strconv.ParseFloat(v, 32); e == nil {
+			p.Length = float32(f)
+		}
+	}
+
+	if v, in := record["traversal_time"]; in && len(v) > 0 {
+		if n, e := strconv.Atoi(v); e == nil {
+			p.TraversalTime = n
+		}
+	}
+
+	if v, in := record["stair_count"]; in && len(v) > 0 {
+		if n, e := strconv.Atoi(v); e == nil {
+			p.StairCount = n
+		}
+	}
+
+	if v, in := record["max_slope"]; in && len(v) > 0 {
+		if f, e := strconv.ParseFloat(v, 32); e == nil {
+			p.MaxSlope = float32(f)
+		}
+	}
+
+	if v, in := record["min_width"]; in && len(v) > 0 {
+		if f, e := strconv.ParseFloat(v, 32); e == nil {
+			p.MinWidth = float32(f)
+		}
+	}
+
+	return p, nil
+}
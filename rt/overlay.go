@@ -0,0 +1,231 @@
+// Copyright 2016 Patrick Brosi
+// Authors: info@patrickbrosi.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+// Package rt merges GTFS-Realtime FeedMessages (TripUpdate, VehiclePosition,
+// Alert) against a parsed static Feed, producing read-only snapshots that can
+// be queried by stop, route or trip.
+package rt
+
+import (
+	gtfsrt "github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"github.com/patrickbr/gtfsparser"
+	"sync"
+	"time"
+)
+
+// Overlay merges realtime updates onto a static Feed. It is safe for
+// concurrent use; cur and poll are guarded by mu so StartPolling's background
+// Apply calls cannot race with readers such as PredictedArrivals.
+type Overlay struct {
+	feed *gtfsparser.Feed
+
+	mu  sync.RWMutex
+	cur *Snapshot
+
+	poll *poller
+}
+
+// NewOverlay creates an Overlay bound to the given static feed. The feed is
+// not modified; all realtime state lives in the snapshots returned by Apply.
+func NewOverlay(feed *gtfsparser.Feed) *Overlay {
+	return &Overlay{
+		feed: feed,
+		cur:  newSnapshot(),
+	}
+}
+
+// Apply merges a FeedMessage into the overlay and returns the resulting
+// Snapshot. TripUpdates for unknown trips, StopTimeUpdates for unknown stops
+// and VehiclePositions whose route does not resolve to a known route are
+// skipped; alerts are kept as given, their informed entity ids are not
+// checked against the feed. StopTime arrivals are resolved against the
+// current service day (the current calendar day, or the previous one before
+// serviceDayCutoverHour), since GTFS-Realtime carries no service-day
+// information of its own.
+func (o *Overlay) Apply(msg *gtfsrt.FeedMessage) (*Snapshot, error) {
+	snap := newSnapshot()
+	serviceDay := startOfDay(time.Now())
+
+	for _, ent := range msg.GetEntity() {
+		if tu := ent.GetTripUpdate(); tu != nil {
+			o.applyTripUpdate(snap, tu, serviceDay)
+		}
+		if vp := ent.GetVehicle(); vp != nil {
+			o.applyVehiclePosition(snap, vp)
+		}
+		if al := ent.GetAlert(); al != nil {
+			o.applyAlert(snap, ent.GetId(), al)
+		}
+	}
+
+	o.mu.Lock()
+	o.cur = snap
+	o.mu.Unlock()
+
+	return snap, nil
+}
+
+// Snapshot returns the most recently applied Snapshot, or an empty one if
+// Apply has not been called yet.
+func (o *Overlay) Snapshot() *Snapshot {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.cur
+}
+
+// PredictedArrivals returns the predicted arrivals at stopId between from and
+// to, sorted by predicted arrival time.
+func (o *Overlay) PredictedArrivals(stopId string, from, to time.Time) []*Arrival {
+	return o.Snapshot().arrivalsForStop(stopId, from, to)
+}
+
+// VehiclesForRoute returns the last known vehicle positions for routeId.
+func (o *Overlay) VehiclesForRoute(routeId string) []*VehiclePosition {
+	return o.Snapshot().vehiclesForRoute(routeId)
+}
+
+// ActiveAlertsForTrip returns alerts whose informed entities select tripId.
+func (o *Overlay) ActiveAlertsForTrip(tripId string) []*Alert {
+	return o.Snapshot().alertsForTrip(tripId)
+}
+
+// serviceDayCutoverHour is the local hour before which Apply still
+// attributes realtime updates to the previous calendar day's service.
+// GTFS stop_times.txt allows arrival/departure times past 24:00:00 for
+// trips that run into the early morning but still belong to the prior
+// service day (e.g. a 25:00:00 arrival is 01:00 the next day); without
+// this, a trip polled shortly after real midnight is anchored to the
+// wrong day and its predicted arrival is off by 24 hours.
+const serviceDayCutoverHour = 4
+
+func startOfDay(t time.Time) time.Time {
+	if t.Hour() < serviceDayCutoverHour {
+		t = t.AddDate(0, 0, -1)
+	}
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func (o *Overlay) applyTripUpdate(snap *Snapshot, tu *gtfsrt.TripUpdate, serviceDay time.Time) {
+	tripId := tu.GetTrip().GetTripId()
+	trip, ok := o.feed.Trips[tripId]
+	if !ok {
+		return
+	}
+
+	upd := make(map[string]stopUpdate, len(tu.GetStopTimeUpdate()))
+	for _, stu := range tu.GetStopTimeUpdate() {
+		stopId := stu.GetStopId()
+		if stopId == "" {
+			continue
+		}
+		if _, ok := o.feed.Stops[stopId]; !ok {
+			continue
+		}
+		upd[stopId] = stopUpdate{
+			arrivalDelay:   stu.GetArrival().GetDelay(),
+			departureDelay: stu.GetDeparture().GetDelay(),
+			hasArrival:     stu.GetArrival() != nil,
+			hasDeparture:   stu.GetDeparture() != nil,
+			skipped:        stu.GetScheduleRelationship() == gtfsrt.TripUpdate_StopTimeUpdate_SKIPPED,
+		}
+	}
+
+	// propagate the last seen delay forward over stops that have no update
+	// of their own, per-sequence in the static timetable.
+	lastDelay := int32(0)
+	for _, st := range trip.StopTimes {
+		u, hasUpdate := upd[st.Stop.Id]
+		if hasUpdate {
+			if u.hasArrival {
+				lastDelay = u.arrivalDelay
+			} else if u.hasDeparture {
+				lastDelay = u.departureDelay
+			}
+		}
+
+		if hasUpdate && u.skipped {
+			continue
+		}
+
+		scheduled := serviceDay.Add(time.Duration(st.Arrival_time.SecondsSinceMidnight()) * time.Second)
+		predicted := scheduled.Add(time.Duration(lastDelay) * time.Second)
+		snap.addArrival(&Arrival{
+			TripId:    tripId,
+			StopId:    st.Stop.Id,
+			RouteId:   trip.Route.Id,
+			Scheduled: scheduled,
+			Predicted: predicted,
+			Delay:     lastDelay,
+		})
+	}
+}
+
+func (o *Overlay) applyVehiclePosition(snap *Snapshot, vp *gtfsrt.VehiclePosition) {
+	routeId := vp.GetTrip().GetRouteId()
+	if routeId == "" {
+		if trip, ok := o.feed.Trips[vp.GetTrip().GetTripId()]; ok {
+			routeId = trip.Route.Id
+		}
+	}
+	if routeId == "" {
+		return
+	}
+	if _, ok := o.feed.Routes[routeId]; !ok {
+		return
+	}
+
+	snap.addVehicle(&VehiclePosition{
+		VehicleId: vp.GetVehicle().GetId(),
+		TripId:    vp.GetTrip().GetTripId(),
+		RouteId:   routeId,
+		Lat:       vp.GetPosition().GetLatitude(),
+		Lon:       vp.GetPosition().GetLongitude(),
+		Timestamp: time.Unix(int64(vp.GetTimestamp()), 0),
+	})
+}
+
+func (o *Overlay) applyAlert(snap *Snapshot, id string, al *gtfsrt.Alert) {
+	alert := &Alert{
+		Id:          id,
+		HeaderText:  translatedText(al.GetHeaderText()),
+		Description: translatedText(al.GetDescriptionText()),
+	}
+
+	for _, sel := range al.GetInformedEntity() {
+		if tripId := sel.GetTrip().GetTripId(); tripId != "" {
+			alert.TripIds = append(alert.TripIds, tripId)
+		}
+		if routeId := sel.GetRouteId(); routeId != "" {
+			alert.RouteIds = append(alert.RouteIds, routeId)
+		}
+		if stopId := sel.GetStopId(); stopId != "" {
+			alert.StopIds = append(alert.StopIds, stopId)
+		}
+	}
+
+	snap.addAlert(alert)
+}
+
+func translatedText(t *gtfsrt.TranslatedString) string {
+	if t == nil || len(t.GetTranslation()) == 0 {
+		return ""
+	}
+	for _, tr := range t.GetTranslation() {
+		if tr.GetLanguage() == "en" {
+			return tr.GetText()
+		}
+	}
+	return t.GetTranslation()[0].GetText()
+}
+
+type stopUpdate struct {
+	arrivalDelay   int32
+	departureDelay int32
+	hasArrival     bool
+	hasDeparture   bool
+	skipped        bool
+}
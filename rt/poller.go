@@ -0,0 +1,108 @@
+// Copyright 2016 Patrick Brosi
+// Authors: info@patrickbrosi.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package rt
+
+import (
+	"fmt"
+	gtfsrt "github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"github.com/golang/protobuf/proto"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+type poller struct {
+	stop chan struct{}
+}
+
+// StartPolling fetches url on interval, applying each successfully fetched
+// FeedMessage to the overlay. ETag/Last-Modified headers from the previous
+// response are sent back on the next request so unchanged feeds are not
+// re-parsed. Polling runs until the returned stop function is called.
+func (o *Overlay) StartPolling(url string, interval time.Duration) (stopFn func(), err error) {
+	o.mu.Lock()
+	if o.poll != nil {
+		o.mu.Unlock()
+		return nil, fmt.Errorf("overlay is already polling")
+	}
+
+	p := &poller{stop: make(chan struct{})}
+	o.poll = p
+	o.mu.Unlock()
+
+	client := http.DefaultClient
+	etag := ""
+	lastModified := ""
+
+	fetch := func() {
+		req, e := http.NewRequest("GET", url, nil)
+		if e != nil {
+			return
+		}
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+
+		resp, e := client.Do(req)
+		if e != nil {
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode == http.StatusNotModified {
+			return
+		}
+		if resp.StatusCode != http.StatusOK {
+			return
+		}
+
+		body, e := ioutil.ReadAll(resp.Body)
+		if e != nil {
+			return
+		}
+
+		msg := &gtfsrt.FeedMessage{}
+		if e := proto.Unmarshal(body, msg); e != nil {
+			return
+		}
+
+		if _, e := o.Apply(msg); e != nil {
+			return
+		}
+
+		if et := resp.Header.Get("ETag"); et != "" {
+			etag = et
+		}
+		if lm := resp.Header.Get("Last-Modified"); lm != "" {
+			lastModified = lm
+		}
+	}
+
+	go func() {
+		fetch()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fetch()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(p.stop)
+		o.mu.Lock()
+		o.poll = nil
+		o.mu.Unlock()
+	}, nil
+}
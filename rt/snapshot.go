@@ -0,0 +1,97 @@
+// Copyright 2016 Patrick Brosi
+// Authors: info@patrickbrosi.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package rt
+
+import (
+	"sort"
+	"time"
+)
+
+// Arrival is a single predicted arrival at a stop, derived from a TripUpdate.
+// Scheduled and Predicted are absolute timestamps, resolved against the
+// service day the TripUpdate was applied on (the calendar day of Apply, or
+// the previous one if Apply ran before serviceDayCutoverHour) - this lets them fall past
+// midnight (for after-midnight service) or be compared across a query
+// window that itself spans midnight without any wraparound handling.
+type Arrival struct {
+	TripId    string
+	StopId    string
+	RouteId   string
+	Scheduled time.Time
+	Predicted time.Time
+	Delay     int32
+}
+
+// VehiclePosition is the last known position of a vehicle serving a trip.
+type VehiclePosition struct {
+	VehicleId string
+	TripId    string
+	RouteId   string
+	Lat       float32
+	Lon       float32
+	Timestamp time.Time
+}
+
+// Alert is a service alert, keyed by the entities it informs.
+type Alert struct {
+	Id          string
+	HeaderText  string
+	Description string
+	TripIds     []string
+	RouteIds    []string
+	StopIds     []string
+}
+
+// Snapshot is an immutable view of the realtime state produced by a single
+// Overlay.Apply call.
+type Snapshot struct {
+	arrivalsByStop  map[string][]*Arrival
+	vehiclesByRoute map[string][]*VehiclePosition
+	alertsByTrip    map[string][]*Alert
+}
+
+func newSnapshot() *Snapshot {
+	return &Snapshot{
+		arrivalsByStop:  make(map[string][]*Arrival),
+		vehiclesByRoute: make(map[string][]*VehiclePosition),
+		alertsByTrip:    make(map[string][]*Alert),
+	}
+}
+
+func (s *Snapshot) addArrival(a *Arrival) {
+	s.arrivalsByStop[a.StopId] = append(s.arrivalsByStop[a.StopId], a)
+}
+
+func (s *Snapshot) addVehicle(v *VehiclePosition) {
+	s.vehiclesByRoute[v.RouteId] = append(s.vehiclesByRoute[v.RouteId], v)
+}
+
+func (s *Snapshot) addAlert(a *Alert) {
+	for _, tripId := range a.TripIds {
+		s.alertsByTrip[tripId] = append(s.alertsByTrip[tripId], a)
+	}
+}
+
+func (s *Snapshot) arrivalsForStop(stopId string, from, to time.Time) []*Arrival {
+	var res []*Arrival
+	for _, a := range s.arrivalsByStop[stopId] {
+		if !a.Predicted.Before(from) && !a.Predicted.After(to) {
+			res = append(res, a)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Predicted.Before(res[j].Predicted) })
+	return res
+}
+
+func (s *Snapshot) vehiclesForRoute(routeId string) []*VehiclePosition {
+	return s.vehiclesByRoute[routeId]
+}
+
+func (s *Snapshot) alertsForTrip(tripId string) []*Alert {
+	return s.alertsByTrip[tripId]
+}
@@ -0,0 +1,44 @@
+// Copyright 2016 Patrick Brosi
+// Authors: info@patrickbrosi.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"errors"
+	"github.com/patrickbr/gtfsparser/gtfs"
+)
+
+func createTranslation(record map[string]string, opts *ParseOptions) (t *gtfs.Translation, err error) {
+	if _, in := record["table_name"]; !in {
+		return nil, errors.New("table_name is required.")
+	}
+	if _, in := record["field_name"]; !in {
+		return nil, errors.New("field_name is required.")
+	}
+	if _, in := record["language"]; !in {
+		return nil, errors.New("language is required.")
+	}
+
+	t = new(gtfs.Translation)
+
+	t.Table_name = record["table_name"]
+	t.Field_name = record["field_name"]
+	t.Language = record["language"]
+	t.Translation = record["translation"]
+	t.Record_id = record["record_id"]
+	t.Record_sub_id = record["record_sub_id"]
+	t.Field_value = record["field_value"]
+
+	if len(t.Record_id) == 0 && len(t.Field_value) == 0 {
+		if opts.UseDefValueOnError {
+			// nothing we can match this translation to, drop it silently
+			return nil, nil
+		}
+		return nil, errors.New("either record_id or field_value must be set.")
+	}
+
+	return t, nil
+}
@@ -0,0 +1,464 @@
+// Copyright 2016 Patrick Brosi
+// Authors: info@patrickbrosi.de
+//
+// Use of this source code is governed by a GPL v2
+// license that can be found in the LICENSE file
+
+package gtfsparser
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"io"
+	neturl "net/url"
+	"os"
+	opath "path"
+	"strconv"
+)
+
+// tableWriter is implemented by every per-table writer function below.
+type tableWriter func(feed *Feed, w io.Writer) error
+
+var writeTables = []struct {
+	Name string
+	Fn   tableWriter
+}{
+	{"agency.txt", (*Feed).writeAgencies},
+	{"stops.txt", (*Feed).writeStops},
+	{"routes.txt", (*Feed).writeRoutes},
+	{"trips.txt", (*Feed).writeTrips},
+	{"stop_times.txt", (*Feed).writeStopTimes},
+	{"calendar.txt", (*Feed).writeCalendar},
+	{"calendar_dates.txt", (*Feed).writeCalendarDates},
+	{"shapes.txt", (*Feed).writeShapes},
+	{"frequencies.txt", (*Feed).writeFrequencies},
+	{"fare_attributes.txt", (*Feed).writeFareAttributes},
+	{"fare_rules.txt", (*Feed).writeFareRules},
+	{"transfers.txt", (*Feed).writeTransfers},
+	{"feed_info.txt", (*Feed).writeFeedInfos},
+}
+
+// Write serializes the feed as a GTFS directory of .txt files under path,
+// creating it if necessary. Tables with no records are skipped entirely,
+// except that stop_times.txt is written whenever the feed has trips, and
+// calendar.txt and calendar_dates.txt whenever it has services, even if
+// that leaves them with only a header row.
+func (feed *Feed) Write(path string) error {
+	if e := os.MkdirAll(path, 0755); e != nil {
+		return e
+	}
+
+	for _, t := range writeTables {
+		if feed.tableEmpty(t.Name) {
+			continue
+		}
+
+		f, e := os.Create(opath.Join(path, t.Name))
+		if e != nil {
+			return e
+		}
+
+		e = t.Fn(feed, f)
+		f.Close()
+		if e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// WriteZip serializes the feed as a GTFS zip archive written to w.
+func (feed *Feed) WriteZip(w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	for _, t := range writeTables {
+		if feed.tableEmpty(t.Name) {
+			continue
+		}
+
+		f, e := zw.Create(t.Name)
+		if e != nil {
+			return e
+		}
+
+		if e := t.Fn(feed, f); e != nil {
+			return e
+		}
+	}
+
+	return zw.Close()
+}
+
+func (feed *Feed) tableEmpty(name string) bool {
+	switch name {
+	case "agency.txt":
+		return len(feed.Agencies) == 0
+	case "stops.txt":
+		return len(feed.Stops) == 0
+	case "routes.txt":
+		return len(feed.Routes) == 0
+	case "trips.txt":
+		return len(feed.Trips) == 0
+	case "stop_times.txt":
+		return len(feed.Trips) == 0
+	case "calendar.txt", "calendar_dates.txt":
+		return len(feed.Services) == 0
+	case "shapes.txt":
+		return len(feed.Shapes) == 0
+	case "frequencies.txt":
+		for _, trip := range feed.Trips {
+			if len(trip.Frequencies) > 0 {
+				return false
+			}
+		}
+		return true
+	case "fare_attributes.txt":
+		return len(feed.FareAttributes) == 0
+	case "fare_rules.txt":
+		for _, fa := range feed.FareAttributes {
+			if len(fa.Rules) > 0 {
+				return false
+			}
+		}
+		return true
+	case "transfers.txt":
+		return len(feed.Transfers) == 0
+	case "feed_info.txt":
+		return len(feed.FeedInfos) == 0
+	}
+	return true
+}
+
+func (feed *Feed) writeAgencies(w io.Writer) error {
+	csvw := csv.NewWriter(w)
+	csvw.Write([]string{"agency_id", "agency_name", "agency_url", "agency_timezone", "agency_lang", "agency_phone", "agency_fare_url", "agency_email"})
+
+	for _, a := range feed.Agencies {
+		csvw.Write([]string{
+			a.Id,
+			a.Name,
+			urlString(a.Url),
+			a.Timezone,
+			a.Lang,
+			a.Phone,
+			urlString(a.Fare_url),
+			a.Email,
+		})
+	}
+
+	csvw.Flush()
+	return csvw.Error()
+}
+
+func (feed *Feed) writeStops(w io.Writer) error {
+	csvw := csv.NewWriter(w)
+	csvw.Write([]string{"stop_id", "stop_code", "stop_name", "stop_desc", "stop_lat", "stop_lon", "zone_id", "stop_url", "location_type", "parent_station", "stop_timezone", "wheelchair_boarding"})
+
+	for _, s := range feed.Stops {
+		parent := ""
+		if s.Parent_station != nil {
+			parent = s.Parent_station.Id
+		}
+
+		csvw.Write([]string{
+			s.Id,
+			s.Code,
+			s.Name,
+			s.Desc,
+			formatFloat(s.Lat),
+			formatFloat(s.Lon),
+			s.Zone_id,
+			urlString(s.Url),
+			formatOptEnum(s.Location_type),
+			parent,
+			s.Timezone,
+			formatOptEnum(s.Wheelchair_boarding),
+		})
+	}
+
+	csvw.Flush()
+	return csvw.Error()
+}
+
+func (feed *Feed) writeRoutes(w io.Writer) error {
+	csvw := csv.NewWriter(w)
+	csvw.Write([]string{"route_id", "agency_id", "route_short_name", "route_long_name", "route_desc", "route_type", "route_url", "route_color", "route_text_color"})
+
+	for _, r := range feed.Routes {
+		agencyId := ""
+		if r.Agency != nil {
+			agencyId = r.Agency.Id
+		}
+
+		csvw.Write([]string{
+			r.Id,
+			agencyId,
+			r.Short_name,
+			r.Long_name,
+			r.Desc,
+			strconv.Itoa(int(r.Type)),
+			urlString(r.Url),
+			r.Color,
+			r.Text_color,
+		})
+	}
+
+	csvw.Flush()
+	return csvw.Error()
+}
+
+func (feed *Feed) writeTrips(w io.Writer) error {
+	csvw := csv.NewWriter(w)
+	csvw.Write([]string{"route_id", "service_id", "trip_id", "trip_headsign", "trip_short_name", "direction_id", "block_id", "shape_id", "wheelchair_accessible", "bikes_allowed"})
+
+	for _, t := range feed.Trips {
+		shapeId := ""
+		if t.Shape != nil {
+			shapeId = t.Shape.Id
+		}
+
+		csvw.Write([]string{
+			t.Route.Id,
+			t.Service.Id,
+			t.Id,
+			t.Headsign,
+			t.Short_name,
+			strconv.Itoa(int(t.Direction_id)),
+			t.Block_id,
+			shapeId,
+			formatOptEnum(t.Wheelchair_accessible),
+			formatOptEnum(t.Bikes_allowed),
+		})
+	}
+
+	csvw.Flush()
+	return csvw.Error()
+}
+
+func (feed *Feed) writeStopTimes(w io.Writer) error {
+	csvw := csv.NewWriter(w)
+	csvw.Write([]string{"trip_id", "arrival_time", "departure_time", "stop_id", "stop_sequence", "stop_headsign", "pickup_type", "drop_off_type", "shape_dist_traveled", "timepoint"})
+
+	for _, t := range feed.Trips {
+		for _, st := range t.StopTimes {
+			dist := ""
+			if st.HasDistanceTraveled() {
+				dist = formatFloat(st.Shape_dist_traveled)
+			}
+
+			csvw.Write([]string{
+				t.Id,
+				st.Arrival_time.String(),
+				st.Departure_time.String(),
+				st.Stop.Id,
+				strconv.Itoa(int(st.Sequence)),
+				st.Headsign,
+				formatOptEnum(st.Pickup_type),
+				formatOptEnum(st.Drop_off_type),
+				dist,
+				strconv.Itoa(int(st.Timepoint)),
+			})
+		}
+	}
+
+	csvw.Flush()
+	return csvw.Error()
+}
+
+func (feed *Feed) writeCalendar(w io.Writer) error {
+	csvw := csv.NewWriter(w)
+	csvw.Write([]string{"service_id", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday", "start_date", "end_date"})
+
+	for _, s := range feed.Services {
+		if !s.HasRegularSchedule() {
+			continue
+		}
+
+		csvw.Write([]string{
+			s.Id,
+			formatBool(s.Daymap[1]),
+			formatBool(s.Daymap[2]),
+			formatBool(s.Daymap[3]),
+			formatBool(s.Daymap[4]),
+			formatBool(s.Daymap[5]),
+			formatBool(s.Daymap[6]),
+			formatBool(s.Daymap[0]),
+			s.Start_date.String(),
+			s.End_date.String(),
+		})
+	}
+
+	csvw.Flush()
+	return csvw.Error()
+}
+
+func (feed *Feed) writeCalendarDates(w io.Writer) error {
+	csvw := csv.NewWriter(w)
+	csvw.Write([]string{"service_id", "date", "exception_type"})
+
+	for _, s := range feed.Services {
+		for date, exceptionType := range s.Exceptions {
+			csvw.Write([]string{s.Id, date.String(), strconv.Itoa(int(exceptionType))})
+		}
+	}
+
+	csvw.Flush()
+	return csvw.Error()
+}
+
+func (feed *Feed) writeShapes(w io.Writer) error {
+	csvw := csv.NewWriter(w)
+	csvw.Write([]string{"shape_id", "shape_pt_lat", "shape_pt_lon", "shape_pt_sequence", "shape_dist_traveled"})
+
+	for _, shape := range feed.Shapes {
+		for _, p := range shape.Points {
+			dist := ""
+			if p.HasDistanceTraveled() {
+				dist = formatFloat(p.Dist_traveled)
+			}
+
+			csvw.Write([]string{
+				shape.Id,
+				formatFloat(p.Lat),
+				formatFloat(p.Lon),
+				strconv.Itoa(int(p.Sequence)),
+				dist,
+			})
+		}
+	}
+
+	csvw.Flush()
+	return csvw.Error()
+}
+
+func (feed *Feed) writeFrequencies(w io.Writer) error {
+	csvw := csv.NewWriter(w)
+	csvw.Write([]string{"trip_id", "start_time", "end_time", "headway_secs", "exact_times"})
+
+	for _, t := range feed.Trips {
+		for _, f := range t.Frequencies {
+			csvw.Write([]string{
+				t.Id,
+				f.Start_time.String(),
+				f.End_time.String(),
+				strconv.Itoa(f.Headway_secs),
+				formatOptEnum(f.Exact_times),
+			})
+		}
+	}
+
+	csvw.Flush()
+	return csvw.Error()
+}
+
+func (feed *Feed) writeFareAttributes(w io.Writer) error {
+	csvw := csv.NewWriter(w)
+	csvw.Write([]string{"fare_id", "price", "currency_type", "payment_method", "transfers", "transfer_duration"})
+
+	for _, fa := range feed.FareAttributes {
+		transfers := ""
+		if fa.Transfers >= 0 {
+			transfers = strconv.Itoa(int(fa.Transfers))
+		}
+
+		csvw.Write([]string{
+			fa.Id,
+			strconv.FormatFloat(float64(fa.Price), 'f', -1, 32),
+			fa.Currency_type,
+			strconv.Itoa(int(fa.Payment_method)),
+			transfers,
+			strconv.Itoa(fa.Transfer_duration),
+		})
+	}
+
+	csvw.Flush()
+	return csvw.Error()
+}
+
+func (feed *Feed) writeFareRules(w io.Writer) error {
+	csvw := csv.NewWriter(w)
+	csvw.Write([]string{"fare_id", "route_id", "origin_id", "destination_id", "contains_id"})
+
+	for _, fa := range feed.FareAttributes {
+		for _, r := range fa.Rules {
+			routeId := ""
+			if r.Route != nil {
+				routeId = r.Route.Id
+			}
+
+			csvw.Write([]string{fa.Id, routeId, r.Origin_id, r.Destination_id, r.Contains_id})
+		}
+	}
+
+	csvw.Flush()
+	return csvw.Error()
+}
+
+func (feed *Feed) writeTransfers(w io.Writer) error {
+	csvw := csv.NewWriter(w)
+	csvw.Write([]string{"from_stop_id", "to_stop_id", "transfer_type", "min_transfer_time"})
+
+	for _, t := range feed.Transfers {
+		minTime := ""
+		if t.Min_transfer_time >= 0 {
+			minTime = strconv.Itoa(t.Min_transfer_time)
+		}
+
+		csvw.Write([]string{t.From_stop.Id, t.To_stop.Id, formatOptEnum(t.Transfer_type), minTime})
+	}
+
+	csvw.Flush()
+	return csvw.Error()
+}
+
+func (feed *Feed) writeFeedInfos(w io.Writer) error {
+	csvw := csv.NewWriter(w)
+	csvw.Write([]string{"feed_publisher_name", "feed_publisher_url", "feed_lang", "feed_start_date", "feed_end_date", "feed_version", "feed_contact_phone"})
+
+	for _, fi := range feed.FeedInfos {
+		csvw.Write([]string{
+			fi.Publisher_name,
+			urlString(fi.Publisher_url),
+			fi.Lang,
+			fi.Start_date.String(),
+			fi.End_date.String(),
+			fi.Version,
+			fi.Phone,
+		})
+	}
+
+	csvw.Flush()
+	return csvw.Error()
+}
+
+func urlString(u *neturl.URL) string {
+	if u == nil {
+		return ""
+	}
+	return u.String()
+}
+
+// formatOptEnum formats a GTFS enum field whose zero value means "no info
+// specified" per spec, the same value an empty CSV cell would parse back to.
+// Do not use this for fields where 0 is itself a meaningful, distinct value
+// (e.g. direction_id, timepoint) - write those with strconv.Itoa instead, or
+// this silently turns a real 0 into a blank cell that re-parses as a
+// different default.
+func formatOptEnum(v int8) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.Itoa(int(v))
+}
+
+func formatFloat(v float32) string {
+	return strconv.FormatFloat(float64(v), 'f', -1, 32)
+}
+
+func formatBool(v bool) string {
+	if v {
+		return "1"
+	}
+	return "0"
+}